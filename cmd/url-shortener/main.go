@@ -0,0 +1,40 @@
+// Command url-shortener runs the URL shortener HTTP server.
+package main
+
+import (
+	"flag"
+	"html/template"
+	"log"
+
+	"github.com/daskol/url-shortener-go/internal/config"
+	"github.com/daskol/url-shortener-go/internal/handlers"
+	"github.com/daskol/url-shortener-go/internal/metrics"
+	"github.com/daskol/url-shortener-go/internal/server"
+	"github.com/daskol/url-shortener-go/internal/storage"
+)
+
+var tplIndexFiles = []string{"templates/index.html"}
+
+func main() {
+	flag.Parse()
+
+	log.Println("URL Shortener")
+
+	cfg := config.Load(*config.Path)
+
+	store, err := storage.New(&cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("use storage: " + cfg.UrlStorage)
+
+	go metrics.Serve(cfg.MetricsAddr)
+	metrics.StartSweeper(store, cfg.CleanupInterval, nil)
+
+	tplIndex := template.Must(template.ParseFiles(tplIndexFiles...))
+	h := handlers.New(&cfg, store, tplIndex)
+
+	srv := server.New(&cfg, h)
+	log.Fatal(srv.ListenAndServe())
+}
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+var bucketUrls = []byte("urls")
+
+func init() {
+	RegisterStorage("bolt", func(config StorageConfig) (UrlStorage, error) {
+		return NewBoltStorage(config.ExpiringTime, config.UriLength, config.Settings["database"])
+	})
+}
+
+type boltEntry struct {
+	Entry   StoredEntry `json:"entry"`
+	Expires time.Time   `json:"expires"`
+}
+
+// BoltStorage is a UrlStorage backed by a local BoltDB file. Like
+// MapStorage, entries expire lazily on Get.
+type BoltStorage struct {
+	db           *bolt.DB
+	expiringTime time.Duration
+	uriLength    int
+}
+
+// NewBoltStorage opens (creating if necessary) the BoltDB database at path
+// and brings its schema up to date, refusing to open a database written by
+// a newer binary.
+func NewBoltStorage(expiringTime time.Duration, uriLength int, path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStorage{
+		db:           db,
+		expiringTime: expiringTime,
+		uriLength:    uriLength,
+	}, nil
+}
+
+func (s *BoltStorage) newUri(tx *bolt.Tx) Uri {
+	bucket := tx.Bucket(bucketUrls)
+	buf := make([]byte, s.uriLength)
+	for {
+		for i := range buf {
+			buf[i] = uriAlphabet[rand.Intn(len(uriAlphabet))]
+		}
+		uri := Uri("/" + string(buf))
+		if bucket.Get([]byte(uri)) == nil {
+			return uri
+		}
+	}
+}
+
+// Put stores entry under a freshly generated Uri and returns it.
+func (s *BoltStorage) Put(entry StoredEntry, expiringTime time.Duration) Uri {
+	var uri Uri
+
+	s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketUrls)
+		uri = s.newUri(tx)
+
+		var expires time.Time
+		if expiringTime > 0 {
+			expires = time.Now().Add(expiringTime)
+		}
+
+		data, err := json.Marshal(boltEntry{Entry: entry, Expires: expires})
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(uri), data)
+	})
+
+	return uri
+}
+
+// PutCustom stores entry under uri, failing with ErrUriTaken if it is
+// already in use.
+func (s *BoltStorage) PutCustom(uri Uri, entry StoredEntry, expiringTime time.Duration) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketUrls)
+
+		if bucket.Get([]byte(uri)) != nil {
+			return ErrUriTaken
+		}
+
+		var expires time.Time
+		if expiringTime > 0 {
+			expires = time.Now().Add(expiringTime)
+		}
+
+		data, err := json.Marshal(boltEntry{Entry: entry, Expires: expires})
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(uri), data)
+	})
+}
+
+// Get resolves uri to its StoredEntry. A Uri whose expiry time has passed is
+// removed and reported as not found; a Uri marked StateDeleted is also
+// reported as not found, but its tombstone is kept so the Uri is never
+// reissued.
+func (s *BoltStorage) Get(uri Uri) (StoredEntry, bool) {
+	var stored boltEntry
+	var found bool
+
+	s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketUrls)
+		data := bucket.Get([]byte(uri))
+		if data == nil {
+			return nil
+		}
+
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+
+		if !stored.Expires.IsZero() && time.Now().After(stored.Expires) {
+			return bucket.Delete([]byte(uri))
+		}
+
+		if stored.Entry.State != StateDeleted {
+			found = true
+		}
+		return nil
+	})
+
+	if !found {
+		return StoredEntry{}, false
+	}
+	return stored.Entry, true
+}
+
+// Delete marks uri as StateDeleted if key matches its DeletionKey,
+// reporting whether it did so.
+func (s *BoltStorage) Delete(uri Uri, key string) bool {
+	var found bool
+
+	s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketUrls)
+		data := bucket.Get([]byte(uri))
+		if data == nil {
+			return nil
+		}
+
+		var stored boltEntry
+		if err := json.Unmarshal(data, &stored); err != nil {
+			return err
+		}
+
+		if stored.Entry.State == StateDeleted || stored.Entry.DeletionKey != key {
+			return nil
+		}
+
+		stored.Entry.State = StateDeleted
+
+		updated, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+
+		found = true
+		return bucket.Put([]byte(uri), updated)
+	})
+
+	return found
+}
+
+// Len reports the number of entries currently in storage.
+func (s *BoltStorage) Len() int {
+	var n int
+
+	s.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(bucketUrls).Stats().KeyN
+		return nil
+	})
+
+	return n
+}
+
+// Sweep removes every entry whose expiry time has passed and reports how
+// many were removed.
+func (s *BoltStorage) Sweep() int {
+	removed := 0
+	now := time.Now()
+
+	s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketUrls)
+		cursor := bucket.Cursor()
+
+		var expired [][]byte
+
+		for key, data := cursor.First(); key != nil; key, data = cursor.Next() {
+			var stored boltEntry
+			if err := json.Unmarshal(data, &stored); err != nil {
+				continue
+			}
+			if !stored.Expires.IsZero() && now.After(stored.Expires) {
+				expired = append(expired, append([]byte(nil), key...))
+			}
+		}
+
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+			removed++
+		}
+
+		return nil
+	})
+
+	return removed
+}
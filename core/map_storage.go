@@ -0,0 +1,171 @@
+package core
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const uriAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// deletionKeyLength is chosen to make deletion keys infeasible to guess,
+// unlike the short, collision-checked Uri.
+const deletionKeyLength = 24
+
+// NewDeletionKey generates a random key to hand back to the creator of an
+// entry. It must be presented to UrlStorage.Delete to delete that entry.
+func NewDeletionKey() string {
+	buf := make([]byte, deletionKeyLength)
+	for i := range buf {
+		buf[i] = uriAlphabet[rand.Intn(len(uriAlphabet))]
+	}
+	return string(buf)
+}
+
+func init() {
+	RegisterStorage("map", func(config StorageConfig) (UrlStorage, error) {
+		return NewMapStorage(config.ExpiringTime, config.UriLength)
+	})
+}
+
+type mapEntry struct {
+	entry   StoredEntry
+	expires time.Time
+}
+
+// MapStorage is a process-local, in-memory UrlStorage. Entries do not
+// survive a restart and expire lazily: a stale entry is only dropped the
+// next time it is looked up with Get.
+type MapStorage struct {
+	mu           sync.Mutex
+	entries      map[Uri]mapEntry
+	expiringTime time.Duration
+	uriLength    int
+}
+
+// NewMapStorage creates an empty MapStorage. A non-positive expiringTime
+// means entries are stored forever.
+func NewMapStorage(expiringTime time.Duration, uriLength int) (*MapStorage, error) {
+	return &MapStorage{
+		entries:      make(map[Uri]mapEntry),
+		expiringTime: expiringTime,
+		uriLength:    uriLength,
+	}, nil
+}
+
+func (s *MapStorage) newUri() Uri {
+	buf := make([]byte, s.uriLength)
+	for i := range buf {
+		buf[i] = uriAlphabet[rand.Intn(len(uriAlphabet))]
+	}
+	return Uri("/" + string(buf))
+}
+
+// Put stores entry under a freshly generated Uri and returns it.
+func (s *MapStorage) Put(entry StoredEntry, expiringTime time.Duration) Uri {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var uri Uri
+	for {
+		uri = s.newUri()
+		if _, ok := s.entries[uri]; !ok {
+			break
+		}
+	}
+
+	var expires time.Time
+	if expiringTime > 0 {
+		expires = time.Now().Add(expiringTime)
+	}
+
+	s.entries[uri] = mapEntry{entry: entry, expires: expires}
+	return uri
+}
+
+// PutCustom stores entry under uri, failing with ErrUriTaken if it is
+// already in use.
+func (s *MapStorage) PutCustom(uri Uri, entry StoredEntry, expiringTime time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[uri]; ok {
+		return ErrUriTaken
+	}
+
+	var expires time.Time
+	if expiringTime > 0 {
+		expires = time.Now().Add(expiringTime)
+	}
+
+	s.entries[uri] = mapEntry{entry: entry, expires: expires}
+	return nil
+}
+
+// Get resolves uri to its StoredEntry. A Uri whose expiry time has passed is
+// removed and reported as not found; a Uri marked StateDeleted is also
+// reported as not found, but its tombstone is kept so the Uri is never
+// reissued.
+func (s *MapStorage) Get(uri Uri) (StoredEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.entries[uri]
+	if !ok {
+		return StoredEntry{}, false
+	}
+
+	if !stored.expires.IsZero() && time.Now().After(stored.expires) {
+		delete(s.entries, uri)
+		return StoredEntry{}, false
+	}
+
+	if stored.entry.State == StateDeleted {
+		return StoredEntry{}, false
+	}
+
+	return stored.entry, true
+}
+
+// Delete marks uri as StateDeleted if key matches its DeletionKey,
+// reporting whether it did so.
+func (s *MapStorage) Delete(uri Uri, key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.entries[uri]
+	if !ok || stored.entry.State == StateDeleted || stored.entry.DeletionKey != key {
+		return false
+	}
+
+	stored.entry.State = StateDeleted
+	s.entries[uri] = stored
+	return true
+}
+
+// Len reports the number of entries currently in storage.
+func (s *MapStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.entries)
+}
+
+// Sweep removes every entry whose expiry time has passed and reports how
+// many were removed.
+func (s *MapStorage) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+
+	for uri, stored := range s.entries {
+		if !stored.expires.IsZero() && now.After(stored.expires) {
+			delete(s.entries, uri)
+			removed++
+		}
+	}
+
+	return removed
+}
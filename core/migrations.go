@@ -0,0 +1,77 @@
+package core
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+// CURRENT_MIGRATE_VERSION is the schema version this binary knows how to
+// read and write. Bump it and append a migration func whenever the stored
+// value format changes (e.g. adding creation timestamps, hit counts,
+// soft-delete state).
+const CURRENT_MIGRATE_VERSION = 1
+
+var bucketConf = []byte("conf")
+var keyMigrateVersion = []byte("migrate_version")
+
+// migration is one step applied inside the startup migration transaction.
+// Index i in migrations brings the schema from version i to version i+1.
+type migration func(tx *bolt.Tx) error
+
+var migrations = []migration{
+	migrateCreateUrlsBucket,
+}
+
+// migrate brings db's "conf" bucket up to CURRENT_MIGRATE_VERSION, running
+// every migration newer than the stored version inside a single
+// bolt.Update transaction. It refuses to open a database whose stored
+// version is newer than CURRENT_MIGRATE_VERSION, since that means it was
+// last written by a newer binary.
+func migrate(db *bolt.DB) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(bucketConf)
+		if err != nil {
+			return err
+		}
+
+		version := readMigrateVersion(bucket)
+		if version > CURRENT_MIGRATE_VERSION {
+			return fmt.Errorf(
+				"core: database schema version %d is newer than this binary supports (%d)",
+				version, CURRENT_MIGRATE_VERSION)
+		}
+
+		for i := version; i < len(migrations); i++ {
+			if err := migrations[i](tx); err != nil {
+				return err
+			}
+			log.Printf("core: applied migration %d\n", i+1)
+		}
+
+		return writeMigrateVersion(bucket, CURRENT_MIGRATE_VERSION)
+	})
+}
+
+func readMigrateVersion(bucket *bolt.Bucket) int {
+	data := bucket.Get(keyMigrateVersion)
+	if data == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(data))
+}
+
+func writeMigrateVersion(bucket *bolt.Bucket, version int) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(version))
+	return bucket.Put(keyMigrateVersion, buf)
+}
+
+// migrateCreateUrlsBucket is migration 1: create the "urls" bucket that
+// every version of the schema has relied on.
+func migrateCreateUrlsBucket(tx *bolt.Tx) error {
+	_, err := tx.CreateBucketIfNotExists(bucketUrls)
+	return err
+}
@@ -0,0 +1,189 @@
+// Package postgres provides a PostgreSQL-backed core.UrlStorage. Importing
+// the package registers it under the name "postgres"; it is not referenced
+// directly anywhere else in the codebase.
+package postgres
+
+import (
+	"database/sql"
+	"log"
+	"math/rand"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/daskol/url-shortener-go/core"
+)
+
+const uriAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS urls (
+	uri TEXT PRIMARY KEY,
+	type INTEGER NOT NULL,
+	state INTEGER NOT NULL DEFAULT 0,
+	url TEXT NOT NULL DEFAULT '',
+	content BYTEA,
+	content_type TEXT NOT NULL DEFAULT '',
+	deletion_key TEXT NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS urls_expires_at_idx ON urls (expires_at);
+`
+
+func init() {
+	core.RegisterStorage("postgres", func(config core.StorageConfig) (core.UrlStorage, error) {
+		return NewPostgresStorage(config.ExpiringTime, config.UriLength, config.Settings["dsn"])
+	})
+}
+
+// PostgresStorage is a durable core.UrlStorage backed by PostgreSQL. Expired
+// rows are left in place for the periodic cleanup sweeper to reap; Get
+// filters them out via the expires_at index.
+type PostgresStorage struct {
+	db        *sql.DB
+	uriLength int
+}
+
+// NewPostgresStorage opens a connection pool to dsn and ensures the urls
+// table exists.
+func NewPostgresStorage(expiringTime time.Duration, uriLength int, dsn string) (*PostgresStorage, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		return nil, err
+	}
+
+	return &PostgresStorage{db: db, uriLength: uriLength}, nil
+}
+
+func (s *PostgresStorage) newUri() core.Uri {
+	buf := make([]byte, s.uriLength)
+	for {
+		for i := range buf {
+			buf[i] = uriAlphabet[rand.Intn(len(uriAlphabet))]
+		}
+		uri := core.Uri("/" + string(buf))
+
+		var exists bool
+		row := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM urls WHERE uri = $1)", string(uri))
+		if err := row.Scan(&exists); err == nil && !exists {
+			return uri
+		}
+	}
+}
+
+// Put stores entry under a freshly generated Uri and returns it. A
+// non-positive expiringTime stores the entry forever.
+func (s *PostgresStorage) Put(entry core.StoredEntry, expiringTime time.Duration) core.Uri {
+	uri := s.newUri()
+
+	var expires sql.NullTime
+	if expiringTime > 0 {
+		expires = sql.NullTime{Time: time.Now().Add(expiringTime), Valid: true}
+	}
+
+	if _, err := s.db.Exec(
+		"INSERT INTO urls (uri, type, url, content, content_type, deletion_key, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		string(uri), int(entry.Type), string(entry.Url), entry.Content, entry.ContentType, entry.DeletionKey, expires); err != nil {
+		log.Printf("postgres: Put(%s) failed: %v", uri, err)
+	}
+
+	return uri
+}
+
+// PutCustom stores entry under uri, failing with core.ErrUriTaken if it is
+// already in use.
+func (s *PostgresStorage) PutCustom(uri core.Uri, entry core.StoredEntry, expiringTime time.Duration) error {
+	var expires sql.NullTime
+	if expiringTime > 0 {
+		expires = sql.NullTime{Time: time.Now().Add(expiringTime), Valid: true}
+	}
+
+	result, err := s.db.Exec(
+		"INSERT INTO urls (uri, type, url, content, content_type, deletion_key, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (uri) DO NOTHING",
+		string(uri), int(entry.Type), string(entry.Url), entry.Content, entry.ContentType, entry.DeletionKey, expires)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return core.ErrUriTaken
+	}
+
+	return nil
+}
+
+// Get resolves uri to its StoredEntry, ignoring rows whose expires_at has
+// passed. A row with state StateDeleted is also reported as not found, but
+// is left in place so the uri is never reissued.
+func (s *PostgresStorage) Get(uri core.Uri) (core.StoredEntry, bool) {
+	var entry core.StoredEntry
+	var entryType, state int
+	var url string
+
+	row := s.db.QueryRow(
+		"SELECT type, state, url, content, content_type, deletion_key FROM urls WHERE uri = $1 AND (expires_at IS NULL OR expires_at > now())",
+		string(uri))
+
+	if err := row.Scan(&entryType, &state, &url, &entry.Content, &entry.ContentType, &entry.DeletionKey); err != nil {
+		return core.StoredEntry{}, false
+	}
+
+	if core.EntryState(state) == core.StateDeleted {
+		return core.StoredEntry{}, false
+	}
+
+	entry.Type = core.EntryType(entryType)
+	entry.Url = core.Url(url)
+
+	return entry, true
+}
+
+// Delete marks uri as StateDeleted if key matches its deletion_key,
+// reporting whether it did so.
+func (s *PostgresStorage) Delete(uri core.Uri, key string) bool {
+	result, err := s.db.Exec(
+		"UPDATE urls SET state = $1 WHERE uri = $2 AND deletion_key = $3 AND state != $1",
+		int(core.StateDeleted), string(uri), key)
+	if err != nil {
+		return false
+	}
+
+	n, err := result.RowsAffected()
+	return err == nil && n > 0
+}
+
+// Len reports the number of entries currently in storage.
+func (s *PostgresStorage) Len() int {
+	var n int
+	row := s.db.QueryRow("SELECT count(*) FROM urls WHERE expires_at IS NULL OR expires_at > now()")
+	if err := row.Scan(&n); err != nil {
+		return 0
+	}
+	return n
+}
+
+// Sweep deletes every row whose expires_at has passed and reports how many
+// were removed, using the urls_expires_at_idx index.
+func (s *PostgresStorage) Sweep() int {
+	result, err := s.db.Exec("DELETE FROM urls WHERE expires_at IS NOT NULL AND expires_at <= now()")
+	if err != nil {
+		return 0
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0
+	}
+
+	return int(n)
+}
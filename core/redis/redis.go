@@ -0,0 +1,191 @@
+// Package redis provides a Redis-backed core.UrlStorage. Importing the
+// package registers it under the name "redis"; it is not referenced
+// directly anywhere else in the codebase.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math/rand"
+	"strconv"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+
+	"github.com/daskol/url-shortener-go/core"
+)
+
+const uriAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func init() {
+	core.RegisterStorage("redis", func(config core.StorageConfig) (core.UrlStorage, error) {
+		db, err := strconv.Atoi(config.Settings["db"])
+		if err != nil {
+			db = 0
+		}
+
+		return NewRedisStorage(config.ExpiringTime, config.UriLength, RedisOptions{
+			Addr:     config.Settings["addr"],
+			Password: config.Settings["password"],
+			DB:       db,
+		})
+	})
+}
+
+// RedisOptions configures the connection to the Redis server.
+type RedisOptions struct {
+	Addr     string
+	Password string
+	DB       int
+}
+
+// RedisStorage is a core.UrlStorage backed by Redis. TTLs are enforced
+// natively by Redis, so there is nothing to sweep lazily or otherwise.
+type RedisStorage struct {
+	client    *goredis.Client
+	uriLength int
+}
+
+// NewRedisStorage connects to the Redis server described by opts.
+func NewRedisStorage(expiringTime time.Duration, uriLength int, opts RedisOptions) (*RedisStorage, error) {
+	client := goredis.NewClient(&goredis.Options{
+		Addr:     opts.Addr,
+		Password: opts.Password,
+		DB:       opts.DB,
+	})
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		return nil, err
+	}
+
+	return &RedisStorage{client: client, uriLength: uriLength}, nil
+}
+
+func (s *RedisStorage) newUri(ctx context.Context) core.Uri {
+	buf := make([]byte, s.uriLength)
+	for {
+		for i := range buf {
+			buf[i] = uriAlphabet[rand.Intn(len(uriAlphabet))]
+		}
+		uri := core.Uri("/" + string(buf))
+		if n, err := s.client.Exists(ctx, string(uri)).Result(); err == nil && n == 0 {
+			return uri
+		}
+	}
+}
+
+// Put stores entry under a freshly generated Uri and returns it. A
+// non-positive expiringTime stores the entry forever.
+func (s *RedisStorage) Put(entry core.StoredEntry, expiringTime time.Duration) core.Uri {
+	ctx := context.Background()
+	uri := s.newUri(ctx)
+
+	ttl := expiringTime
+	if ttl <= 0 {
+		ttl = 0
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("redis: Put(%s) failed to marshal entry: %v", uri, err)
+		return uri
+	}
+
+	if err := s.client.Set(ctx, string(uri), data, ttl).Err(); err != nil {
+		log.Printf("redis: Put(%s) failed: %v", uri, err)
+	}
+
+	return uri
+}
+
+// PutCustom stores entry under uri, failing with core.ErrUriTaken if it is
+// already in use. The SETNX semantics make this atomic even under
+// concurrent requests for the same uri.
+func (s *RedisStorage) PutCustom(uri core.Uri, entry core.StoredEntry, expiringTime time.Duration) error {
+	ctx := context.Background()
+
+	ttl := expiringTime
+	if ttl <= 0 {
+		ttl = 0
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.client.SetNX(ctx, string(uri), data, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return core.ErrUriTaken
+	}
+
+	return nil
+}
+
+// Get resolves uri to its StoredEntry. A Uri marked StateDeleted is
+// reported as not found, but the key is left in place (with its original
+// TTL) so it is never reissued while that TTL lasts.
+func (s *RedisStorage) Get(uri core.Uri) (core.StoredEntry, bool) {
+	data, err := s.client.Get(context.Background(), string(uri)).Bytes()
+	if err != nil {
+		return core.StoredEntry{}, false
+	}
+
+	var entry core.StoredEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return core.StoredEntry{}, false
+	}
+
+	if entry.State == core.StateDeleted {
+		return core.StoredEntry{}, false
+	}
+
+	return entry, true
+}
+
+// Delete marks uri as StateDeleted if key matches its DeletionKey,
+// reporting whether it did so.
+func (s *RedisStorage) Delete(uri core.Uri, key string) bool {
+	ctx := context.Background()
+
+	data, err := s.client.Get(ctx, string(uri)).Bytes()
+	if err != nil {
+		return false
+	}
+
+	var entry core.StoredEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return false
+	}
+
+	if entry.State == core.StateDeleted || entry.DeletionKey != key {
+		return false
+	}
+
+	entry.State = core.StateDeleted
+
+	updated, err := json.Marshal(entry)
+	if err != nil {
+		return false
+	}
+
+	ttl, err := s.client.TTL(ctx, string(uri)).Result()
+	if err != nil {
+		ttl = 0
+	}
+
+	return s.client.Set(ctx, string(uri), updated, ttl).Err() == nil
+}
+
+// Len reports the number of keys in the configured Redis database.
+func (s *RedisStorage) Len() int {
+	n, err := s.client.DBSize(context.Background()).Result()
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
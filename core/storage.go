@@ -0,0 +1,132 @@
+// Package core defines the URL storage abstraction used by the shortener
+// and the registry that lets additional backends plug into it.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUriTaken is returned by PutCustom when the requested Uri is already in
+// use by another entry.
+var ErrUriTaken = errors.New("core: uri already taken")
+
+// Url is a long URL submitted for shortening.
+type Url string
+
+// Uri is the short URI handed back to the client, e.g. "/abc123".
+type Uri string
+
+// EntryType distinguishes what kind of content a StoredEntry holds.
+type EntryType int
+
+const (
+	// TypeRedirect is a classic shortened URL: visiting its Uri issues a
+	// redirect to Url.
+	TypeRedirect EntryType = iota
+	// TypePaste is arbitrary text or binary content uploaded by the
+	// caller: visiting its Uri serves Content back with ContentType.
+	TypePaste
+)
+
+// EntryState tracks whether a StoredEntry is still live.
+type EntryState int
+
+const (
+	// StateActive is a normal, resolvable entry.
+	StateActive EntryState = iota
+	// StateDeleted marks an entry its owner deleted. The Uri is kept
+	// occupied by the tombstone so it can never be reissued to a
+	// different Url or paste.
+	StateDeleted
+)
+
+// StoredEntry is whatever a Uri resolves to, either a redirect target or a
+// paste's content.
+type StoredEntry struct {
+	Type  EntryType
+	State EntryState
+
+	// Url is set when Type is TypeRedirect.
+	Url Url
+
+	// Content and ContentType are set when Type is TypePaste.
+	Content     []byte
+	ContentType string
+
+	// DeletionKey is generated when the entry is created and must be
+	// presented to Delete it.
+	DeletionKey string
+}
+
+// UrlStorage is implemented by every storage backend (in-memory map, Bolt,
+// Redis, PostgreSQL, ...). Put generates a fresh Uri for entry and persists
+// it with the given time to live. Get resolves a previously stored Uri back
+// to its StoredEntry, honouring expiry.
+type UrlStorage interface {
+	Put(entry StoredEntry, expiringTime time.Duration) Uri
+
+	// PutCustom stores entry under the caller-chosen uri, failing with
+	// ErrUriTaken if it is already in use.
+	PutCustom(uri Uri, entry StoredEntry, expiringTime time.Duration) error
+
+	Get(uri Uri) (StoredEntry, bool)
+
+	// Delete marks uri as StateDeleted if key matches its DeletionKey,
+	// reporting whether it did so. The entry is kept, not removed, so the
+	// Uri can never be reissued.
+	Delete(uri Uri, key string) bool
+
+	// Len reports the number of entries currently in storage.
+	Len() int
+}
+
+// Sweeper is implemented by backends that only expire entries lazily on
+// Get (MapStorage, BoltStorage, PostgresStorage) and therefore need a
+// periodic sweep to actually reclaim expired entries. Backends with native
+// TTL support (Redis) have no need to implement it.
+type Sweeper interface {
+	// Sweep removes every entry whose expiry time has passed and reports
+	// how many were removed.
+	Sweep() int
+}
+
+// StorageConfig carries the settings common to every backend plus a bag of
+// backend-specific settings taken verbatim from that backend's TOML section.
+type StorageConfig struct {
+	ExpiringTime time.Duration
+	UriLength    int
+	Settings     map[string]string
+}
+
+// StorageFactory builds a UrlStorage from a StorageConfig. Backends register
+// one under their name via RegisterStorage.
+type StorageFactory func(config StorageConfig) (UrlStorage, error)
+
+var storageRegistry = map[string]StorageFactory{}
+
+// RegisterStorage makes a storage backend available under name. It is meant
+// to be called from a backend package's init() so that importing the
+// package for side effects is enough to make the backend selectable, e.g.:
+//
+//	import _ "github.com/daskol/url-shortener-go/core/redis"
+//
+// RegisterStorage panics if name is already registered, since that indicates
+// two backends fighting over the same config key.
+func RegisterStorage(name string, factory StorageFactory) {
+	if _, ok := storageRegistry[name]; ok {
+		panic("core: storage already registered: " + name)
+	}
+	storageRegistry[name] = factory
+}
+
+// NewStorage looks up the backend registered under name and builds it from
+// config.
+func NewStorage(name string, config StorageConfig) (UrlStorage, error) {
+	factory, ok := storageRegistry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage %q", name)
+	}
+	return factory(config)
+}
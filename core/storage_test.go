@@ -0,0 +1,141 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testStorage is the UrlStorage plus Sweeper behavior shared by every lazily
+// expiring backend (MapStorage, BoltStorage), which is what these tests
+// exercise.
+type testStorage interface {
+	UrlStorage
+	Sweeper
+}
+
+// storageConstructors returns one constructor per backend under test, each
+// building a fresh, empty store.
+func storageConstructors(t *testing.T) map[string]func() testStorage {
+	t.Helper()
+
+	return map[string]func() testStorage{
+		"map": func() testStorage {
+			s, err := NewMapStorage(time.Hour, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		},
+		"bolt": func() testStorage {
+			s, err := NewBoltStorage(time.Hour, 8, filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { s.db.Close() })
+			return s
+		},
+	}
+}
+
+func TestStoragePutGet(t *testing.T) {
+	for name, newStorage := range storageConstructors(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStorage()
+
+			entry := StoredEntry{Type: TypeRedirect, Url: "https://example.com"}
+			uri := s.Put(entry, time.Hour)
+
+			got, ok := s.Get(uri)
+			if !ok {
+				t.Fatal("Get reported not found for a freshly stored entry")
+			}
+			if got.Url != entry.Url {
+				t.Errorf("Url = %q, want %q", got.Url, entry.Url)
+			}
+			if s.Len() != 1 {
+				t.Errorf("Len() = %d, want 1", s.Len())
+			}
+		})
+	}
+}
+
+func TestStoragePutCustom(t *testing.T) {
+	for name, newStorage := range storageConstructors(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStorage()
+
+			if err := s.PutCustom("/mine", StoredEntry{Type: TypeRedirect, Url: "https://a.example"}, time.Hour); err != nil {
+				t.Fatalf("PutCustom() = %v, want nil", err)
+			}
+
+			if err := s.PutCustom("/mine", StoredEntry{Type: TypeRedirect, Url: "https://b.example"}, time.Hour); err != ErrUriTaken {
+				t.Fatalf("PutCustom() = %v, want ErrUriTaken", err)
+			}
+		})
+	}
+}
+
+func TestStorageExpiry(t *testing.T) {
+	for name, newStorage := range storageConstructors(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStorage()
+
+			uri := s.Put(StoredEntry{Type: TypeRedirect, Url: "https://example.com"}, time.Nanosecond)
+			time.Sleep(time.Millisecond)
+
+			if _, ok := s.Get(uri); ok {
+				t.Fatal("Get returned an entry past its expiry time")
+			}
+			if s.Len() != 0 {
+				t.Errorf("Len() = %d, want 0 after lazy expiry on Get", s.Len())
+			}
+		})
+	}
+}
+
+func TestStorageDelete(t *testing.T) {
+	for name, newStorage := range storageConstructors(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStorage()
+
+			entry := StoredEntry{Type: TypeRedirect, Url: "https://example.com", DeletionKey: "secret"}
+			uri := s.Put(entry, time.Hour)
+
+			if s.Delete(uri, "wrong") {
+				t.Fatal("Delete succeeded with the wrong key")
+			}
+			if !s.Delete(uri, "secret") {
+				t.Fatal("Delete failed with the right key")
+			}
+			if _, ok := s.Get(uri); ok {
+				t.Fatal("Get returned a deleted entry")
+			}
+			if s.Len() != 1 {
+				t.Errorf("Len() = %d, want 1 (tombstone kept so the uri can't be reissued)", s.Len())
+			}
+		})
+	}
+}
+
+func TestStorageSweep(t *testing.T) {
+	for name, newStorage := range storageConstructors(t) {
+		t.Run(name, func(t *testing.T) {
+			s := newStorage()
+
+			s.Put(StoredEntry{Type: TypeRedirect, Url: "https://stale.example"}, time.Nanosecond)
+			fresh := s.Put(StoredEntry{Type: TypeRedirect, Url: "https://fresh.example"}, time.Hour)
+			time.Sleep(time.Millisecond)
+
+			if n := s.Sweep(); n != 1 {
+				t.Fatalf("Sweep() removed %d entries, want 1", n)
+			}
+			if s.Len() != 1 {
+				t.Errorf("Len() = %d, want 1 after sweeping the expired entry", s.Len())
+			}
+			if _, ok := s.Get(fresh); !ok {
+				t.Error("Sweep removed an entry that had not expired")
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+// Package config defines the shortener's flags and its Config struct, and
+// merges them with an optional TOML file.
+package config
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+var Path = flag.String("config", "", "Path to *.toml config.")
+
+var (
+	urlStorageKind  = flag.String("url-storage", "map", "Set how to store URLs.")
+	uriLength       = flag.Int("uri-length", 8, "Length of randomly generated URI.")
+	ttl             = flag.Int64("ttl", 3600, "URI's time to live.")
+	host            = flag.String("host", "localhost", "Address to listen.")
+	port            = flag.Int("port", 8080, "Port to listen.")
+	hostname        = flag.String("hostname", "", "Force host name definition for URL building.")
+	boltDatabase    = flag.String("bolt-db", "url-storage-bolt.db", "Path to Bolt DB database file.")
+	maxPasteSize    = flag.Int64("max-paste-size", 1<<20, "Maximum size in bytes of an uploaded paste.")
+	apiKey          = flag.String("api-key", "", "API key required by /api/v1/ write endpoints.")
+	metricsAddr     = flag.String("metrics-addr", "localhost:9100", "Address to serve /metrics on.")
+	cleanupInterval = flag.Duration("cleanup-interval", time.Minute, "How often to sweep expired entries from storage. 0 disables the sweeper.")
+)
+
+type Config struct {
+	Host string
+	Port int
+
+	ExpiringTime    time.Duration `toml:"expiring_time"`
+	HostName        string        `toml:"host_name"`
+	UriLength       int           `toml:"uri_length"`
+	UrlStorage      string        `toml:"url_storage"`
+	MaxPasteSize    int64         `toml:"max_paste_size"`
+	ApiKey          string        `toml:"api_key"`
+	MetricsAddr     string        `toml:"metrics_addr"`
+	CleanupInterval time.Duration `toml:"cleanup_interval"`
+
+	Bolt     BoltStorageConfig     `toml:"bolt-storage"`
+	Redis    RedisStorageConfig    `toml:"redis-storage"`
+	Postgres PostgresStorageConfig `toml:"postgres-storage"`
+}
+
+type BoltStorageConfig struct {
+	Database string `toml:"database"`
+}
+
+type RedisStorageConfig struct {
+	Addr     string `toml:"addr"`
+	Password string `toml:"password"`
+	DB       string `toml:"db"`
+}
+
+type PostgresStorageConfig struct {
+	Dsn string `toml:"dsn"`
+}
+
+// Load builds a Config from the parsed flags, then overlays path (a TOML
+// file) if one is given. flag.Parse must have already run.
+func Load(path string) Config {
+	log.Printf("read config from %s\n", path)
+
+	config := Config{
+		Host:            *host,
+		Port:            *port,
+		HostName:        *hostname,
+		ExpiringTime:    time.Duration(*ttl) * time.Second,
+		UriLength:       *uriLength,
+		UrlStorage:      *urlStorageKind,
+		MaxPasteSize:    *maxPasteSize,
+		ApiKey:          *apiKey,
+		MetricsAddr:     *metricsAddr,
+		CleanupInterval: *cleanupInterval,
+		Bolt: BoltStorageConfig{
+			Database: *boltDatabase,
+		},
+	}
+
+	if len(path) != 0 {
+		if _, err := os.Stat(path); err != nil {
+			log.Fatal("Config file is missing: ", path)
+		}
+
+		if _, err := toml.DecodeFile(path, &config); err != nil {
+			log.Fatal(err)
+		}
+
+		config.ExpiringTime *= time.Second
+
+		if config.ExpiringTime <= 0 {
+			log.Println("ttl of url is set to store urls forever")
+		}
+	}
+
+	// The env var must work whether or not a TOML file is given, so a
+	// deployment can set the API key without needing a config file at all.
+	if config.ApiKey == "" {
+		config.ApiKey = os.Getenv("URL_SHORTENER_API_KEY")
+	}
+
+	return config
+}
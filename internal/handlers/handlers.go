@@ -0,0 +1,427 @@
+// Package handlers implements the shortener's HTTP handlers. They take
+// their dependencies (storage, config, templates) via the Handlers struct
+// instead of package globals, so they can be exercised with a fake storage
+// in table-driven tests without spinning up the full server.
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/daskol/url-shortener-go/core"
+	"github.com/daskol/url-shortener-go/internal/config"
+)
+
+// validSlug matches a custom_ending that is safe to store as a single path
+// segment: ExpandRequest resolves a Uri by path.Base, so anything containing
+// "/" would be stored but could never be looked back up.
+var validSlug = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Handlers holds everything the HTTP handlers need to serve a request.
+type Handlers struct {
+	Config  *config.Config
+	Storage core.UrlStorage
+	Index   *template.Template
+}
+
+// New constructs a Handlers. index may be nil, in which case GET / responds
+// 404 instead of rendering the landing page.
+func New(cfg *config.Config, storage core.UrlStorage, index *template.Template) *Handlers {
+	return &Handlers{Config: cfg, Storage: storage, Index: index}
+}
+
+// getHost tries its best to return the request host.
+func getHost(r *http.Request) string {
+	if r.URL.IsAbs() {
+		host := r.Host
+		// Slice off any port information.
+		if i := strings.Index(host, ":"); i != -1 {
+			host = host[:i]
+		}
+		return host
+	}
+	return r.URL.Host
+}
+
+// formatRequest generates ascii representation of a request
+func formatRequest(r *http.Request) string {
+	// Create return string
+	var request []string
+	// Add the request string
+	url := fmt.Sprintf("%v %v %v", r.Method, r.URL, r.Proto)
+	request = append(request, url)
+	// Add the host
+	request = append(request, fmt.Sprintf("Host: %v", r.Host))
+	// Loop through headers
+	for name, headers := range r.Header {
+		name = strings.ToLower(name)
+		for _, h := range headers {
+			request = append(request, fmt.Sprintf("%v: %v", name, h))
+		}
+	}
+
+	// If this is a POST, add post data
+	if r.Method == "POST" {
+		r.ParseForm()
+		request = append(request, "\n")
+		request = append(request, r.Form.Encode())
+	}
+	// Return the request as a string
+	return strings.Join(request, "\n")
+}
+
+func (h *Handlers) extractHostname(r *http.Request) string {
+	var hostname string
+
+	if schema, ok := r.Header["X-Forwarded-Proto"]; ok {
+		hostname = schema[0]
+	} else {
+		hostname = "http"
+	}
+
+	hostname += "://"
+
+	if forwarded_host, ok := r.Header["X-Forwarded-Host"]; ok {
+		hostname += forwarded_host[0]
+	} else if len(r.Host) > 0 {
+		hostname += r.Host
+	}
+
+	if h.Config.HostName != "" {
+		hostname = h.Config.HostName
+	}
+
+	return hostname
+}
+
+// parseShortenForm parses r's body under a MaxPasteSize cap before anything
+// else can touch it. It must run before any call to r.FormValue or
+// r.ParseForm: those parse the whole body under Go's hardcoded
+// defaultMaxMemory, ignoring MaxPasteSize, and would leave a later
+// http.MaxBytesReader a no-op against an already-drained body.
+func (h *Handlers) parseShortenForm(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, h.Config.MaxPasteSize)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(h.Config.MaxPasteSize)
+	}
+	return r.ParseForm()
+}
+
+// readPaste extracts the paste content and content type from a request
+// already parsed by parseShortenForm, either from an uploaded file ("paste"
+// multipart field) or a plain form value ("paste" field, stored as
+// text/plain).
+func (h *Handlers) readPaste(r *http.Request) (content []byte, contentType string, ok bool) {
+	if file, header, err := r.FormFile("paste"); err == nil {
+		defer file.Close()
+
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, "", false
+		}
+
+		contentType = header.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(data)
+		}
+
+		return data, contentType, true
+	}
+
+	if paste := r.FormValue("paste"); len(paste) > 0 {
+		return []byte(paste), "text/plain; charset=utf-8", true
+	}
+
+	return nil, "", false
+}
+
+func (h *Handlers) ShortRequest(w http.ResponseWriter, r *http.Request) {
+	shorten := func() (location string, deleteKey string, ok bool) {
+		var entry core.StoredEntry
+
+		if err := h.parseShortenForm(w, r); err != nil {
+			http.Error(w, "Request body too large.", http.StatusRequestEntityTooLarge)
+			return "", "", false
+		}
+
+		if content, contentType, ok := h.readPaste(r); ok {
+			entry = core.StoredEntry{Type: core.TypePaste, Content: content, ContentType: contentType}
+		} else if url := r.FormValue("url"); len(url) > 0 {
+			entry = core.StoredEntry{Type: core.TypeRedirect, Url: core.Url(url)}
+		} else {
+			http.Error(w, "No url or paste to shorten.", http.StatusBadRequest)
+			return "", "", false
+		}
+
+		entry.DeletionKey = core.NewDeletionKey()
+
+		uri := h.Storage.Put(entry, h.Config.ExpiringTime)
+		location = h.extractHostname(r) + string(uri)
+
+		return location, entry.DeletionKey, true
+	}
+
+	switch r.Method {
+	case "POST":
+		if location, deleteKey, ok := shorten(); ok {
+			w.Header().Set("Location", location)
+			w.Header().Set("X-Delete-Key", deleteKey)
+			w.WriteHeader(http.StatusCreated)
+		}
+	case "GET":
+		if location, deleteKey, ok := shorten(); ok {
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "%s\nX-Delete-Key: %s\n", location, deleteKey)
+		}
+	case "DELETE":
+		h.deleteShortened(w, r)
+	default:
+		http.Error(w, "", http.StatusMethodNotAllowed)
+	}
+
+}
+
+// deleteShortened handles DELETE /shorten/{uri}, soft-deleting the entry if
+// X-Delete-Key matches the key its creator was handed.
+func (h *Handlers) deleteShortened(w http.ResponseWriter, r *http.Request) {
+	uri := core.Uri("/" + path.Base(r.URL.Path))
+	key := r.Header.Get("X-Delete-Key")
+
+	if len(key) == 0 {
+		http.Error(w, "X-Delete-Key header is required.", http.StatusBadRequest)
+		return
+	}
+
+	if !h.Storage.Delete(uri, key) {
+		http.Error(w, "", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) ExpandRequest(w http.ResponseWriter, r *http.Request) {
+	urlPath := r.URL.Path
+
+	raw := strings.HasSuffix(urlPath, "/raw")
+	if raw {
+		urlPath = strings.TrimSuffix(urlPath, "/raw")
+	}
+
+	linkId := "/" + string(path.Base(urlPath))
+
+	if entry, ok := h.Storage.Get(core.Uri(linkId)); ok {
+		switch entry.Type {
+		case core.TypePaste:
+			contentType := entry.ContentType
+			if raw {
+				contentType = "text/plain; charset=utf-8"
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Write(entry.Content)
+		default:
+			w.Header().Set("Location", string(entry.Url))
+			w.WriteHeader(http.StatusFound)
+		}
+	} else if r.URL.Path == "/" && h.Index != nil {
+		if err := h.Index.Execute(w, nil); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	} else {
+		http.Error(w, "", http.StatusNotFound)
+	}
+}
+
+// apiResponse is the envelope returned by every /api/v1/ endpoint.
+type apiResponse struct {
+	Action string      `json:"action"`
+	Status string      `json:"status"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+func writeApiResponse(w http.ResponseWriter, code int, resp apiResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func writeApiError(w http.ResponseWriter, code int, action, message string) {
+	writeApiResponse(w, code, apiResponse{Action: action, Status: "error", Error: message})
+}
+
+// requireApiKey checks the X-Api-Key header against the configured key. It
+// writes the 401 response itself and reports whether the caller may proceed.
+func (h *Handlers) requireApiKey(w http.ResponseWriter, r *http.Request, action string) bool {
+	if h.Config.ApiKey == "" {
+		return true
+	}
+
+	if r.Header.Get("X-Api-Key") == h.Config.ApiKey {
+		return true
+	}
+
+	writeApiError(w, http.StatusUnauthorized, action, "missing or invalid API key")
+	return false
+}
+
+type shortenRequest struct {
+	Url          string `json:"url"`
+	CustomEnding string `json:"custom_ending"`
+}
+
+type shortenResult struct {
+	Uri       string `json:"uri"`
+	Location  string `json:"location"`
+	DeleteKey string `json:"delete_key"`
+}
+
+// ApiShorten handles POST /api/v1/shorten.
+func (h *Handlers) ApiShorten(w http.ResponseWriter, r *http.Request) {
+	const action = "shorten"
+
+	if r.Method != http.MethodPost {
+		writeApiError(w, http.StatusMethodNotAllowed, action, "method not allowed")
+		return
+	}
+
+	if !h.requireApiKey(w, r, action) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.Config.MaxPasteSize)
+
+	var req shortenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeApiError(w, http.StatusBadRequest, action, "invalid JSON body")
+		return
+	}
+
+	if len(req.Url) == 0 {
+		writeApiError(w, http.StatusBadRequest, action, "url is required")
+		return
+	}
+
+	entry := core.StoredEntry{
+		Type:        core.TypeRedirect,
+		Url:         core.Url(req.Url),
+		DeletionKey: core.NewDeletionKey(),
+	}
+
+	var uri core.Uri
+
+	if req.CustomEnding != "" {
+		if !validSlug.MatchString(req.CustomEnding) {
+			writeApiError(w, http.StatusBadRequest, action, "custom_ending must match [A-Za-z0-9_-]+")
+			return
+		}
+
+		uri = core.Uri("/" + req.CustomEnding)
+		if err := h.Storage.PutCustom(uri, entry, h.Config.ExpiringTime); err != nil {
+			if err == core.ErrUriTaken {
+				writeApiError(w, http.StatusConflict, action, "custom_ending already in use")
+			} else {
+				writeApiError(w, http.StatusInternalServerError, action, err.Error())
+			}
+			return
+		}
+	} else {
+		uri = h.Storage.Put(entry, h.Config.ExpiringTime)
+	}
+
+	writeApiResponse(w, http.StatusCreated, apiResponse{
+		Action: action,
+		Status: "ok",
+		Result: shortenResult{
+			Uri:       string(uri),
+			Location:  h.extractHostname(r) + string(uri),
+			DeleteKey: entry.DeletionKey,
+		},
+	})
+}
+
+type expandResult struct {
+	Url string `json:"url"`
+}
+
+// ApiExpand handles GET /api/v1/expand?uri=/abc123.
+func (h *Handlers) ApiExpand(w http.ResponseWriter, r *http.Request) {
+	const action = "expand"
+
+	uri := core.Uri(r.URL.Query().Get("uri"))
+	if len(uri) == 0 {
+		writeApiError(w, http.StatusBadRequest, action, "uri is required")
+		return
+	}
+
+	entry, ok := h.Storage.Get(uri)
+	if !ok || entry.Type != core.TypeRedirect {
+		writeApiError(w, http.StatusNotFound, action, "uri not found")
+		return
+	}
+
+	writeApiResponse(w, http.StatusOK, apiResponse{
+		Action: action,
+		Status: "ok",
+		Result: expandResult{Url: string(entry.Url)},
+	})
+}
+
+// ApiDelete handles DELETE /api/v1/delete?uri=/abc123.
+func (h *Handlers) ApiDelete(w http.ResponseWriter, r *http.Request) {
+	const action = "delete"
+
+	if r.Method != http.MethodDelete {
+		writeApiError(w, http.StatusMethodNotAllowed, action, "method not allowed")
+		return
+	}
+
+	if !h.requireApiKey(w, r, action) {
+		return
+	}
+
+	uri := core.Uri(r.URL.Query().Get("uri"))
+	if len(uri) == 0 {
+		writeApiError(w, http.StatusBadRequest, action, "uri is required")
+		return
+	}
+
+	// Deletion stays owner-scoped even behind the API key: the caller must
+	// present the same X-Delete-Key handed back by shorten, matching
+	// DELETE /shorten/{uri}. The API key only gates access to the endpoint,
+	// it is not itself a master deletion key.
+	key := r.Header.Get("X-Delete-Key")
+	if len(key) == 0 {
+		writeApiError(w, http.StatusBadRequest, action, "X-Delete-Key header is required")
+		return
+	}
+
+	if !h.Storage.Delete(uri, key) {
+		writeApiError(w, http.StatusNotFound, action, "uri not found")
+		return
+	}
+
+	writeApiResponse(w, http.StatusOK, apiResponse{Action: action, Status: "ok"})
+}
+
+type statsResult struct {
+	Entries int `json:"entries"`
+}
+
+// ApiStats handles GET /api/v1/stats.
+func (h *Handlers) ApiStats(w http.ResponseWriter, r *http.Request) {
+	const action = "stats"
+
+	writeApiResponse(w, http.StatusOK, apiResponse{
+		Action: action,
+		Status: "ok",
+		Result: statsResult{Entries: h.Storage.Len()},
+	})
+}
@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daskol/url-shortener-go/core"
+	"github.com/daskol/url-shortener-go/internal/config"
+)
+
+// fakeStorage is a minimal in-memory core.UrlStorage for exercising
+// handlers without a real backend.
+type fakeStorage struct {
+	entries map[core.Uri]core.StoredEntry
+	nextUri core.Uri
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{entries: make(map[core.Uri]core.StoredEntry)}
+}
+
+func (s *fakeStorage) Put(entry core.StoredEntry, expiringTime time.Duration) core.Uri {
+	uri := s.nextUri
+	if uri == "" {
+		uri = "/fake"
+	}
+	s.entries[uri] = entry
+	return uri
+}
+
+func (s *fakeStorage) PutCustom(uri core.Uri, entry core.StoredEntry, expiringTime time.Duration) error {
+	if _, ok := s.entries[uri]; ok {
+		return core.ErrUriTaken
+	}
+	s.entries[uri] = entry
+	return nil
+}
+
+func (s *fakeStorage) Get(uri core.Uri) (core.StoredEntry, bool) {
+	entry, ok := s.entries[uri]
+	if !ok || entry.State == core.StateDeleted {
+		return core.StoredEntry{}, false
+	}
+	return entry, true
+}
+
+func (s *fakeStorage) Delete(uri core.Uri, key string) bool {
+	entry, ok := s.entries[uri]
+	if !ok || entry.State == core.StateDeleted || entry.DeletionKey != key {
+		return false
+	}
+	entry.State = core.StateDeleted
+	s.entries[uri] = entry
+	return true
+}
+
+func (s *fakeStorage) Len() int {
+	return len(s.entries)
+}
+
+func newTestHandlers(store *fakeStorage) *Handlers {
+	return New(&config.Config{MaxPasteSize: 1 << 20}, store, nil)
+}
+
+func TestShortRequest(t *testing.T) {
+	t.Run("url", func(t *testing.T) {
+		store := newFakeStorage()
+		h := newTestHandlers(store)
+
+		form := url.Values{"url": {"https://example.com"}}
+		req := httptest.NewRequest(http.MethodPost, "/shorten/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		h.ShortRequest(w, req)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusCreated)
+		}
+		if w.Header().Get("X-Delete-Key") == "" {
+			t.Error("X-Delete-Key header not set")
+		}
+		entry, ok := store.Get("/fake")
+		if !ok || entry.Type != core.TypeRedirect || entry.Url != "https://example.com" {
+			t.Fatalf("stored entry = %+v, ok = %v", entry, ok)
+		}
+	})
+
+	t.Run("paste", func(t *testing.T) {
+		store := newFakeStorage()
+		h := newTestHandlers(store)
+
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, err := mw.CreateFormFile("paste", "note.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("hello paste"))
+		mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten/", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+
+		h.ShortRequest(w, req)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusCreated)
+		}
+		entry, ok := store.Get("/fake")
+		if !ok || entry.Type != core.TypePaste || string(entry.Content) != "hello paste" {
+			t.Fatalf("stored entry = %+v, ok = %v", entry, ok)
+		}
+	})
+
+	t.Run("neither url nor paste", func(t *testing.T) {
+		store := newFakeStorage()
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodPost, "/shorten/", strings.NewReader(""))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		h.ShortRequest(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestExpandRequest(t *testing.T) {
+	t.Run("redirect", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/abc123"] = core.StoredEntry{Type: core.TypeRedirect, Url: "https://example.com"}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		w := httptest.NewRecorder()
+		h.ExpandRequest(w, req)
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusFound)
+		}
+		if loc := w.Header().Get("Location"); loc != "https://example.com" {
+			t.Errorf("Location = %q", loc)
+		}
+	})
+
+	t.Run("paste", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/abc123"] = core.StoredEntry{
+			Type:        core.TypePaste,
+			Content:     []byte("<b>hi</b>"),
+			ContentType: "text/html; charset=utf-8",
+		}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		w := httptest.NewRecorder()
+		h.ExpandRequest(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if w.Body.String() != "<b>hi</b>" {
+			t.Errorf("body = %q", w.Body.String())
+		}
+	})
+
+	t.Run("paste raw", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/abc123"] = core.StoredEntry{
+			Type:        core.TypePaste,
+			Content:     []byte("<b>hi</b>"),
+			ContentType: "text/html; charset=utf-8",
+		}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodGet, "/abc123/raw", nil)
+		w := httptest.NewRecorder()
+		h.ExpandRequest(w, req)
+
+		if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+			t.Errorf("Content-Type = %q, want text/plain forced by /raw", ct)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		h := newTestHandlers(newFakeStorage())
+
+		req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+		w := httptest.NewRecorder()
+		h.ExpandRequest(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestApiShorten(t *testing.T) {
+	t.Run("custom ending", func(t *testing.T) {
+		store := newFakeStorage()
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(`{"url":"https://example.com","custom_ending":"mine"}`))
+		w := httptest.NewRecorder()
+		h.ApiShorten(w, req)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want %d, body = %s", w.Code, http.StatusCreated, w.Body.String())
+		}
+		if _, ok := store.Get("/mine"); !ok {
+			t.Error("entry not stored under requested custom_ending")
+		}
+	})
+
+	t.Run("custom ending conflict", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/mine"] = core.StoredEntry{Type: core.TypeRedirect, Url: "https://taken.example"}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(`{"url":"https://example.com","custom_ending":"mine"}`))
+		w := httptest.NewRecorder()
+		h.ApiShorten(w, req)
+
+		if w.Code != http.StatusConflict {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusConflict)
+		}
+	})
+
+	t.Run("custom ending with path separator rejected", func(t *testing.T) {
+		store := newFakeStorage()
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/shorten", strings.NewReader(`{"url":"https://example.com","custom_ending":"a/b"}`))
+		w := httptest.NewRecorder()
+		h.ApiShorten(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+		if store.Len() != 0 {
+			t.Error("invalid custom_ending must not be stored")
+		}
+	})
+}
+
+func TestDeleteShortened(t *testing.T) {
+	t.Run("wrong key", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/abc123"] = core.StoredEntry{Type: core.TypeRedirect, Url: "https://example.com", DeletionKey: "secret"}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodDelete, "/shorten/abc123", nil)
+		req.Header.Set("X-Delete-Key", "wrong")
+		w := httptest.NewRecorder()
+		h.ShortRequest(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+		if _, ok := store.Get("/abc123"); !ok {
+			t.Error("entry should still be live after a failed delete")
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/abc123"] = core.StoredEntry{Type: core.TypeRedirect, Url: "https://example.com", DeletionKey: "secret"}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodDelete, "/shorten/abc123", nil)
+		w := httptest.NewRecorder()
+		h.ShortRequest(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("right key blocks reissue", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/abc123"] = core.StoredEntry{Type: core.TypeRedirect, Url: "https://example.com", DeletionKey: "secret"}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodDelete, "/shorten/abc123", nil)
+		req.Header.Set("X-Delete-Key", "secret")
+		w := httptest.NewRecorder()
+		h.ShortRequest(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+		}
+		if _, ok := store.Get("/abc123"); ok {
+			t.Error("entry should be gone after a successful delete")
+		}
+
+		req2 := httptest.NewRequest(http.MethodDelete, "/shorten/abc123", nil)
+		req2.Header.Set("X-Delete-Key", "secret")
+		w2 := httptest.NewRecorder()
+		h.ShortRequest(w2, req2)
+
+		if w2.Code != http.StatusNotFound {
+			t.Fatalf("re-delete status = %d, want %d", w2.Code, http.StatusNotFound)
+		}
+
+		if err := store.PutCustom("/abc123", core.StoredEntry{Type: core.TypeRedirect, Url: "https://other.example"}, time.Hour); err != core.ErrUriTaken {
+			t.Fatalf("PutCustom() on a tombstoned uri = %v, want ErrUriTaken", err)
+		}
+	})
+}
+
+func TestApiDelete(t *testing.T) {
+	t.Run("missing key", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/abc123"] = core.StoredEntry{Type: core.TypeRedirect, Url: "https://example.com", DeletionKey: "secret"}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/delete?uri=/abc123", nil)
+		w := httptest.NewRecorder()
+		h.ApiDelete(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+		if _, ok := store.Get("/abc123"); !ok {
+			t.Error("entry should still be live without X-Delete-Key")
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/abc123"] = core.StoredEntry{Type: core.TypeRedirect, Url: "https://example.com", DeletionKey: "secret"}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/delete?uri=/abc123", nil)
+		req.Header.Set("X-Delete-Key", "wrong")
+		w := httptest.NewRecorder()
+		h.ApiDelete(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("right key", func(t *testing.T) {
+		store := newFakeStorage()
+		store.entries["/abc123"] = core.StoredEntry{Type: core.TypeRedirect, Url: "https://example.com", DeletionKey: "secret"}
+		h := newTestHandlers(store)
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/delete?uri=/abc123", nil)
+		req.Header.Set("X-Delete-Key", "secret")
+		w := httptest.NewRecorder()
+		h.ApiDelete(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body = %s", w.Code, http.StatusOK, w.Body.String())
+		}
+		if _, ok := store.Get("/abc123"); ok {
+			t.Error("entry should be gone after a successful delete")
+		}
+	})
+}
@@ -0,0 +1,148 @@
+// Package metrics exposes Prometheus instrumentation for the URL shortener:
+// request counters, a storage-size gauge, and a background sweeper that
+// reclaims expired entries from backends that only expire lazily.
+package metrics
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/daskol/url-shortener-go/core"
+)
+
+var (
+	shortenRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "url_shortener_shorten_requests_total",
+		Help: "Number of requests that stored a new entry.",
+	})
+	redirectHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "url_shortener_redirect_hits_total",
+		Help: "Number of successful lookups resolved to a stored entry.",
+	})
+	cacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "url_shortener_cache_misses_total",
+		Help: "Number of lookups for a Uri that was not found.",
+	})
+	expiredEntries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "url_shortener_expired_entries_total",
+		Help: "Number of entries reclaimed by the periodic cleanup sweeper.",
+	})
+	storageSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "url_shortener_storage_size",
+		Help: "Number of entries currently held by a storage backend.",
+	}, []string{"backend"})
+)
+
+func init() {
+	prometheus.MustRegister(shortenRequests, redirectHits, cacheMisses, expiredEntries, storageSize)
+}
+
+// Handler serves the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve starts an HTTP server exposing /metrics on addr. It is meant to be
+// run in its own goroutine from main; a failure to bind is fatal, matching
+// how the primary server is started.
+func Serve(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+
+	log.Printf("serving metrics on %s\n", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+// InstrumentedStorage wraps a core.UrlStorage, recording request counters
+// and keeping the storage_size gauge for backend up to date.
+type InstrumentedStorage struct {
+	core.UrlStorage
+	backend string
+}
+
+// Wrap instruments storage, labelling its storage_size gauge with backend
+// (the configured storage kind, e.g. "map" or "bolt"). The request counters
+// carry no backend label.
+func Wrap(backend string, storage core.UrlStorage) *InstrumentedStorage {
+	s := &InstrumentedStorage{UrlStorage: storage, backend: backend}
+	s.reportSize()
+	return s
+}
+
+func (s *InstrumentedStorage) reportSize() {
+	storageSize.WithLabelValues(s.backend).Set(float64(s.UrlStorage.Len()))
+}
+
+// Put records a shorten request in addition to delegating to the wrapped
+// storage.
+func (s *InstrumentedStorage) Put(entry core.StoredEntry, expiringTime time.Duration) core.Uri {
+	uri := s.UrlStorage.Put(entry, expiringTime)
+	shortenRequests.Inc()
+	s.reportSize()
+	return uri
+}
+
+// PutCustom records a shorten request in addition to delegating to the
+// wrapped storage.
+func (s *InstrumentedStorage) PutCustom(uri core.Uri, entry core.StoredEntry, expiringTime time.Duration) error {
+	err := s.UrlStorage.PutCustom(uri, entry, expiringTime)
+	if err == nil {
+		shortenRequests.Inc()
+		s.reportSize()
+	}
+	return err
+}
+
+// Get records a hit or a miss in addition to delegating to the wrapped
+// storage.
+func (s *InstrumentedStorage) Get(uri core.Uri) (core.StoredEntry, bool) {
+	entry, ok := s.UrlStorage.Get(uri)
+	if ok {
+		redirectHits.Inc()
+	} else {
+		cacheMisses.Inc()
+	}
+	return entry, ok
+}
+
+// Delete records the resulting storage size in addition to delegating to
+// the wrapped storage.
+func (s *InstrumentedStorage) Delete(uri core.Uri, key string) bool {
+	ok := s.UrlStorage.Delete(uri, key)
+	if ok {
+		s.reportSize()
+	}
+	return ok
+}
+
+// StartSweeper runs storage's Sweep every interval until stop is closed. It
+// is a no-op if the wrapped storage does not implement core.Sweeper (e.g.
+// Redis, which expires entries natively) or if interval is non-positive.
+func StartSweeper(storage *InstrumentedStorage, interval time.Duration, stop <-chan struct{}) {
+	sweeper, ok := storage.UrlStorage.(core.Sweeper)
+	if !ok || interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if n := sweeper.Sweep(); n > 0 {
+					expiredEntries.Add(float64(n))
+					storage.reportSize()
+					log.Printf("cleanup sweeper: removed %d expired entries\n", n)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
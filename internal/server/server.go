@@ -0,0 +1,26 @@
+// Package server wires a Handlers into an *http.Server.
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/daskol/url-shortener-go/internal/config"
+	"github.com/daskol/url-shortener-go/internal/handlers"
+)
+
+// New builds the *http.Server that serves the shortener's routes.
+func New(cfg *config.Config, h *handlers.Handlers) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/shorten/", h.ShortRequest)
+	mux.HandleFunc("/api/v1/shorten", h.ApiShorten)
+	mux.HandleFunc("/api/v1/expand", h.ApiExpand)
+	mux.HandleFunc("/api/v1/delete", h.ApiDelete)
+	mux.HandleFunc("/api/v1/stats", h.ApiStats)
+	mux.HandleFunc("/", h.ExpandRequest)
+
+	return &http.Server{
+		Addr:    cfg.Host + ":" + strconv.Itoa(cfg.Port),
+		Handler: mux,
+	}
+}
@@ -0,0 +1,49 @@
+// Package storage builds the core.UrlStorage backend selected by a
+// config.Config and instruments it with Prometheus metrics. It is the only
+// place that translates per-backend config sections into core.StorageConfig
+// settings.
+package storage
+
+import (
+	"github.com/daskol/url-shortener-go/core"
+	"github.com/daskol/url-shortener-go/internal/config"
+	"github.com/daskol/url-shortener-go/internal/metrics"
+
+	_ "github.com/daskol/url-shortener-go/core/postgres"
+	_ "github.com/daskol/url-shortener-go/core/redis"
+)
+
+// New builds the storage backend named by cfg.UrlStorage and wraps it with
+// Prometheus instrumentation. Backends register themselves with
+// core.RegisterStorage, either from core itself (map, bolt) or from a
+// subpackage imported here for its side effects (redis, postgres), so
+// adding a new one never requires touching this function.
+func New(cfg *config.Config) (*metrics.InstrumentedStorage, error) {
+	backend, err := core.NewStorage(cfg.UrlStorage, core.StorageConfig{
+		ExpiringTime: cfg.ExpiringTime,
+		UriLength:    cfg.UriLength,
+		Settings:     settings(cfg),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return metrics.Wrap(cfg.UrlStorage, backend), nil
+}
+
+func settings(cfg *config.Config) map[string]string {
+	switch cfg.UrlStorage {
+	case "bolt":
+		return map[string]string{"database": cfg.Bolt.Database}
+	case "redis":
+		return map[string]string{
+			"addr":     cfg.Redis.Addr,
+			"password": cfg.Redis.Password,
+			"db":       cfg.Redis.DB,
+		}
+	case "postgres":
+		return map[string]string{"dsn": cfg.Postgres.Dsn}
+	default:
+		return nil
+	}
+}